@@ -1,29 +1,73 @@
 package client
 
 import (
+    "bufio"
     "bytes"
+    "context"
+    cryptorand "crypto/rand"
+    "encoding/hex"
     "encoding/json"
     "fmt"
     "io"
+    "math/rand"
+    "mime/multipart"
     "net/http"
     "net/url"
+    "path/filepath"
+    "strconv"
+    "strings"
+    "sync"
     "time"
+
+    "ai-coding-agent/client/httpcache"
 )
 
 type Client struct {
     BaseURL string
     APIKey  string
     http    *http.Client
+
+    readDL      *deadlineTimer
+    writeDL     *deadlineTimer
+    retryPolicy RetryPolicy
+    cache       *httpcache.Transport
 }
 
 func New(baseURL, apiKey string) *Client {
     return &Client{
-        BaseURL: stringsTrimRightSlash(baseURL),
-        APIKey:  apiKey,
-        http:    &http.Client{Timeout: 30 * time.Second},
+        BaseURL:     stringsTrimRightSlash(baseURL),
+        APIKey:      apiKey,
+        http:        &http.Client{Timeout: 30 * time.Second},
+        readDL:      newDeadlineTimer(),
+        writeDL:     newDeadlineTimer(),
+        retryPolicy: defaultRetryPolicy,
     }
 }
 
+// SetRetryPolicy overrides the retry policy used for idempotent requests
+// (GET/HEAD, and any request carrying an auto-generated Idempotency-Key).
+func (c *Client) SetRetryPolicy(p RetryPolicy) { c.retryPolicy = p }
+
+// WithCache enables an on-disk HTTP cache for GET /healthz, GET
+// /api/platform and GET /api/file, stored under dir and capped at
+// maxBytes (0 means unbounded). It wraps whatever transport the client
+// was already using.
+func (c *Client) WithCache(dir string, maxBytes int64) error {
+    t, err := httpcache.New(c.http.Transport, dir, maxBytes, c.APIKey)
+    if err != nil { return err }
+    if c.cache != nil { c.cache.Close() }
+    c.cache = t
+    c.http.Transport = t
+    return nil
+}
+
+// PurgeCache removes every entry from the on-disk HTTP cache. It is a
+// no-op if WithCache was never called.
+func (c *Client) PurgeCache() error {
+    if c.cache == nil { return nil }
+    return c.cache.Purge()
+}
+
 func stringsTrimRightSlash(s string) string {
     for len(s) > 0 && s[len(s)-1] == '/' {
         s = s[:len(s)-1]
@@ -31,34 +75,286 @@ func stringsTrimRightSlash(s string) string {
     return s
 }
 
-func (c *Client) request(method, path string, body any) (*http.Response, error) {
-    var rdr io.Reader
+// deadlineTimer guards a single cancel channel that closes when its
+// deadline elapses, so in-flight operations can select on it to abort.
+type deadlineTimer struct {
+    mu     sync.Mutex
+    timer  *time.Timer
+    fired  bool
+    cancel chan struct{}
+}
+
+func newDeadlineTimer() *deadlineTimer {
+    return &deadlineTimer{cancel: make(chan struct{})}
+}
+
+// set arms the timer for t. A zero t clears the deadline, leaving the
+// cancel channel open. A t already in the past closes the channel
+// immediately. Otherwise a fresh channel is installed (if the previous
+// one had already fired) and a timer is armed to close it at t.
+func (d *deadlineTimer) set(t time.Time) {
+    d.mu.Lock()
+    defer d.mu.Unlock()
+    // Stop reports false once the timer has fired, even if its callback is
+    // still waiting on d.mu; treat that as fired so it can't close the
+    // channel handed to the next deadline
+    if d.timer != nil && !d.timer.Stop() { d.fired = true }
+    if d.fired {
+        d.cancel = make(chan struct{})
+        d.fired = false
+    }
+    if t.IsZero() {
+        d.timer = nil
+        return
+    }
+    dur := time.Until(t)
+    if dur <= 0 {
+        d.fired = true
+        close(d.cancel)
+        d.timer = nil
+        return
+    }
+    cur := d.cancel
+    d.timer = time.AfterFunc(dur, func() {
+        d.mu.Lock()
+        defer d.mu.Unlock()
+        if d.cancel == cur {
+            d.fired = true
+            close(cur)
+        }
+    })
+}
+
+func (d *deadlineTimer) channel() <-chan struct{} {
+    d.mu.Lock()
+    defer d.mu.Unlock()
+    return d.cancel
+}
+
+// SetDeadline arms both the read and write deadlines. A zero time.Time
+// clears them.
+func (c *Client) SetDeadline(t time.Time) {
+    c.readDL.set(t)
+    c.writeDL.set(t)
+}
+
+// SetReadDeadline arms the deadline used by read operations (Health,
+// PlatformStatus, ReadFile, GetJob, ...). A zero time.Time clears it.
+func (c *Client) SetReadDeadline(t time.Time) { c.readDL.set(t) }
+
+// SetWriteDeadline arms the deadline used by write operations (WriteFile,
+// DeleteFile, ExecuteChainAsJob, ...). A zero time.Time clears it.
+func (c *Client) SetWriteDeadline(t time.Time) { c.writeDL.set(t) }
+
+// APIError is returned by every Client method on a 4xx/5xx response,
+// decoded from the server's JSON error envelope.
+type APIError struct {
+    Code      string
+    Message   string
+    Status    int
+    Details   map[string]any
+    RequestID string
+}
+
+func (e *APIError) Error() string {
+    if e.Message != "" { return fmt.Sprintf("%s: %s (status %d)", e.Code, e.Message, e.Status) }
+    return fmt.Sprintf("status %d", e.Status)
+}
+
+type errorEnvelope struct {
+    Error struct {
+        Code    string         `json:"code"`
+        Message string         `json:"message"`
+        Details map[string]any `json:"details"`
+    } `json:"error"`
+    RequestID string `json:"requestId"`
+}
+
+// decodeAPIError reads and closes resp.Body, building an APIError from the
+// server's JSON error envelope (or the raw body, if it isn't one).
+func decodeAPIError(resp *http.Response) *APIError {
+    defer resp.Body.Close()
+    b, _ := io.ReadAll(resp.Body)
+    ae := &APIError{Status: resp.StatusCode, RequestID: resp.Header.Get("X-Request-ID")}
+    var env errorEnvelope
+    if err := json.Unmarshal(b, &env); err == nil && (env.Error.Code != "" || env.Error.Message != "") {
+        ae.Code = env.Error.Code
+        ae.Message = env.Error.Message
+        ae.Details = env.Error.Details
+        if env.RequestID != "" { ae.RequestID = env.RequestID }
+        return ae
+    }
+    ae.Message = string(b)
+    return ae
+}
+
+// RetryPolicy controls how Client.request retries a failed attempt of an
+// idempotent request.
+type RetryPolicy struct {
+    MaxAttempts int
+    BaseDelay   time.Duration
+    MaxDelay    time.Duration
+    Jitter      bool
+    RetryOn     func(*http.Response, error) bool
+}
+
+func defaultRetryOn(resp *http.Response, err error) bool {
+    if err != nil { return true }
+    return resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500
+}
+
+var defaultRetryPolicy = RetryPolicy{
+    MaxAttempts: 3,
+    BaseDelay:   200 * time.Millisecond,
+    MaxDelay:    5 * time.Second,
+    Jitter:      true,
+    RetryOn:     defaultRetryOn,
+}
+
+// newIdempotencyKey returns a random key suitable for the Idempotency-Key
+// header, so a chain/job isn't executed twice when a retry races a
+// response that was actually delivered.
+func newIdempotencyKey() string {
+    b := make([]byte, 16)
+    if _, err := cryptorand.Read(b); err != nil { return strconv.FormatInt(time.Now().UnixNano(), 36) }
+    return hex.EncodeToString(b)
+}
+
+func backoffDelay(attempt int, policy RetryPolicy) time.Duration {
+    d := policy.BaseDelay
+    for i := 0; i < attempt; i++ {
+        d *= 2
+        if d >= policy.MaxDelay { d = policy.MaxDelay; break }
+    }
+    if d > policy.MaxDelay { d = policy.MaxDelay }
+    if policy.Jitter && d > 0 { d = time.Duration(rand.Int63n(int64(d) + 1)) }
+    return d
+}
+
+// parseRetryAfter reads the Retry-After header (seconds or HTTP-date) off
+// a response, if present.
+func parseRetryAfter(resp *http.Response) (time.Duration, bool) {
+    if resp == nil { return 0, false }
+    v := resp.Header.Get("Retry-After")
+    if v == "" { return 0, false }
+    if secs, err := strconv.Atoi(v); err == nil { return time.Duration(secs) * time.Second, true }
+    if t, err := http.ParseTime(v); err == nil {
+        if d := time.Until(t); d > 0 { return d, true }
+        return 0, true
+    }
+    return 0, false
+}
+
+// request issues method/path, retrying per the client's RetryPolicy when
+// the request is idempotent (GET/HEAD, or any request carrying an
+// idempotencyKey), and returns an *APIError for any 4xx/5xx response.
+func (c *Client) request(ctx context.Context, method, path string, body any, abort <-chan struct{}, idempotencyKey string) (*http.Response, error) {
+    var bodyBytes []byte
     if body != nil {
         b, err := json.Marshal(body)
         if err != nil { return nil, err }
-        rdr = bytes.NewReader(b)
+        bodyBytes = b
     }
-    req, err := http.NewRequest(method, c.BaseURL+path, rdr)
-    if err != nil { return nil, err }
+    retryable := method == http.MethodGet || method == http.MethodHead || idempotencyKey != ""
+    policy := c.retryPolicy
+
+    for attempt := 0; ; attempt++ {
+        resp, err := c.doOnce(ctx, method, path, bodyBytes, abort, idempotencyKey)
+        if err == nil && resp.StatusCode < 400 { return resp, nil }
+
+        if !retryable || attempt+1 >= policy.MaxAttempts || !policy.RetryOn(resp, err) {
+            if err != nil { return nil, err }
+            return nil, decodeAPIError(resp)
+        }
+        if resp != nil { resp.Body.Close() }
+
+        delay, ok := parseRetryAfter(resp)
+        if !ok { delay = backoffDelay(attempt, policy) }
+        if err := waitBackoff(ctx, abort, delay); err != nil { return nil, err }
+    }
+}
+
+// waitBackoff blocks for d, returning early with ctx.Err() if ctx is
+// canceled or an "aborted" error if abort closes first, so a read/write
+// deadline is honored during the retry backoff and not just within the
+// next doOnce attempt.
+func waitBackoff(ctx context.Context, abort <-chan struct{}, d time.Duration) error {
+    t := time.NewTimer(d)
+    defer t.Stop()
+    select {
+    case <-t.C:
+        return nil
+    case <-ctx.Done():
+        return ctx.Err()
+    case <-abort:
+        return fmt.Errorf("request aborted: deadline exceeded")
+    }
+}
+
+// doOnce performs a single attempt of method/path against the base URL,
+// aborting if ctx is canceled or if abort closes before the response
+// arrives.
+func (c *Client) doOnce(ctx context.Context, method, path string, bodyBytes []byte, abort <-chan struct{}, idempotencyKey string) (*http.Response, error) {
+    var rdr io.Reader
+    if bodyBytes != nil { rdr = bytes.NewReader(bodyBytes) }
+    reqCtx, cancel := context.WithCancel(ctx)
+    req, err := http.NewRequestWithContext(reqCtx, method, c.BaseURL+path, rdr)
+    if err != nil { cancel(); return nil, err }
     req.Header.Set("Content-Type", "application/json")
     if c.APIKey != "" { req.Header.Set("x-api-key", c.APIKey) }
-    return c.http.Do(req)
+    if idempotencyKey != "" { req.Header.Set("Idempotency-Key", idempotencyKey) }
+
+    type result struct {
+        resp *http.Response
+        err  error
+    }
+    done := make(chan result, 1)
+    go func() {
+        resp, err := c.http.Do(req)
+        done <- result{resp, err}
+    }()
+
+    select {
+    case res := <-done:
+        if res.err != nil { cancel(); return nil, res.err }
+        // the body is still read under reqCtx, so release it only when
+        // the caller closes the body
+        res.resp.Body = cancelOnClose{ReadCloser: res.resp.Body, cancel: cancel}
+        return res.resp, nil
+    case <-ctx.Done():
+        cancel()
+        <-done
+        return nil, ctx.Err()
+    case <-abort:
+        cancel()
+        <-done
+        return nil, fmt.Errorf("request aborted: deadline exceeded")
+    }
+}
+
+// cancelOnClose cancels a request's context when its response body is closed.
+type cancelOnClose struct {
+    io.ReadCloser
+    cancel context.CancelFunc
 }
 
+func (b cancelOnClose) Close() error { err := b.ReadCloser.Close(); b.cancel(); return err }
+
 // Health
-func (c *Client) Health() (map[string]any, error) {
-    resp, err := c.request(http.MethodGet, "/healthz", nil)
+func (c *Client) HealthContext(ctx context.Context) (map[string]any, error) {
+    resp, err := c.request(ctx, http.MethodGet, "/healthz", nil, c.readDL.channel(), "")
     if err != nil { return nil, err }
     defer resp.Body.Close()
     var out map[string]any
     if err := json.NewDecoder(resp.Body).Decode(&out); err != nil { return nil, err }
-    if resp.StatusCode >= 400 { return out, fmt.Errorf("status %d", resp.StatusCode) }
     return out, nil
 }
 
+func (c *Client) Health() (map[string]any, error) { return c.HealthContext(context.Background()) }
+
 // Platform
-func (c *Client) PlatformStatus() (map[string]any, error) {
-    resp, err := c.request(http.MethodGet, "/api/platform", nil)
+func (c *Client) PlatformStatusContext(ctx context.Context) (map[string]any, error) {
+    resp, err := c.request(ctx, http.MethodGet, "/api/platform", nil, c.readDL.channel(), "")
     if err != nil { return nil, err }
     defer resp.Body.Close()
     var out map[string]any
@@ -66,8 +362,12 @@ func (c *Client) PlatformStatus() (map[string]any, error) {
     return out, nil
 }
 
-func (c *Client) PlatformInit() (map[string]any, error) {
-    resp, err := c.request(http.MethodPost, "/api/platform/init", map[string]string{})
+func (c *Client) PlatformStatus() (map[string]any, error) {
+    return c.PlatformStatusContext(context.Background())
+}
+
+func (c *Client) PlatformInitContext(ctx context.Context) (map[string]any, error) {
+    resp, err := c.request(ctx, http.MethodPost, "/api/platform/init", map[string]string{}, c.writeDL.channel(), "")
     if err != nil { return nil, err }
     defer resp.Body.Close()
     var out map[string]any
@@ -75,39 +375,149 @@ func (c *Client) PlatformInit() (map[string]any, error) {
     return out, nil
 }
 
+func (c *Client) PlatformInit() (map[string]any, error) {
+    return c.PlatformInitContext(context.Background())
+}
+
 // Files
-func (c *Client) ReadFile(p string) (string, error) {
-    u := fmt.Sprintf("%s/api/file?path=%s", c.BaseURL, url.QueryEscape(p))
-    req, _ := http.NewRequest(http.MethodGet, u, nil)
-    if c.APIKey != "" { req.Header.Set("x-api-key", c.APIKey) }
-    resp, err := c.http.Do(req)
+func (c *Client) ReadFileContext(ctx context.Context, p string) (string, error) {
+    u := "/api/file?path=" + url.QueryEscape(p)
+    resp, err := c.request(ctx, http.MethodGet, u, nil, c.readDL.channel(), "")
     if err != nil { return "", err }
     defer resp.Body.Close()
-    b, _ := io.ReadAll(resp.Body)
-    if resp.StatusCode >= 400 { return "", fmt.Errorf("status %d: %s", resp.StatusCode, string(b)) }
+    b, err := io.ReadAll(resp.Body)
+    if err != nil { return "", err }
     return string(b), nil
 }
 
+func (c *Client) ReadFile(p string) (string, error) { return c.ReadFileContext(context.Background(), p) }
+
+func (c *Client) WriteFileContext(ctx context.Context, p, content string) error {
+    _, err := c.request(ctx, http.MethodPost, "/api/file", map[string]any{"path": p, "content": content}, c.writeDL.channel(), "")
+    return err
+}
+
 func (c *Client) WriteFile(p, content string) error {
-    _, err := c.request(http.MethodPost, "/api/file", map[string]any{"path": p, "content": content})
+    return c.WriteFileContext(context.Background(), p, content)
+}
+
+func (c *Client) DeleteFileContext(ctx context.Context, p string) error {
+    _, err := c.request(ctx, http.MethodPost, "/api/file/delete", map[string]any{"path": p}, c.writeDL.channel(), "")
     return err
 }
 
-func (c *Client) DeleteFile(p string) error {
-    _, err := c.request(http.MethodPost, "/api/file/delete", map[string]any{"path": p})
+func (c *Client) DeleteFile(p string) error { return c.DeleteFileContext(context.Background(), p) }
+
+func (c *Client) MoveFileContext(ctx context.Context, from, to string) error {
+    _, err := c.request(ctx, http.MethodPost, "/api/file/move", map[string]any{"from": from, "to": to}, c.writeDL.channel(), "")
     return err
 }
 
 func (c *Client) MoveFile(from, to string) error {
-    _, err := c.request(http.MethodPost, "/api/file/move", map[string]any{"from": from, "to": to})
-    return err
+    return c.MoveFileContext(context.Background(), from, to)
+}
+
+// WriteFileStreamContext uploads content as multipart/form-data instead of
+// buffering it into a JSON body like WriteFile does, so large generated
+// artifacts don't need to fit in memory twice. size is informational
+// (sent as X-Content-Length-Hint); pass -1 if unknown.
+func (c *Client) WriteFileStreamContext(ctx context.Context, path string, r io.Reader, size int64) error {
+    pr, pw := io.Pipe()
+    mw := multipart.NewWriter(pw)
+    go func() {
+        pw.CloseWithError(func() error {
+            if err := mw.WriteField("path", path); err != nil { return err }
+            part, err := mw.CreateFormFile("file", filepath.Base(path))
+            if err != nil { return err }
+            if _, err := io.Copy(part, r); err != nil { return err }
+            return mw.Close()
+        }())
+    }()
+
+    reqCtx, cancel := context.WithCancel(ctx)
+    defer cancel()
+    req, err := http.NewRequestWithContext(reqCtx, http.MethodPost, c.BaseURL+"/api/file", pr)
+    if err != nil { return err }
+    req.Header.Set("Content-Type", mw.FormDataContentType())
+    if c.APIKey != "" { req.Header.Set("x-api-key", c.APIKey) }
+    if size >= 0 { req.Header.Set("X-Content-Length-Hint", strconv.FormatInt(size, 10)) }
+
+    type result struct {
+        resp *http.Response
+        err  error
+    }
+    done := make(chan result, 1)
+    go func() {
+        resp, err := c.http.Do(req)
+        done <- result{resp, err}
+    }()
+
+    select {
+    case res := <-done:
+        if res.err != nil { return res.err }
+        defer res.resp.Body.Close()
+        if res.resp.StatusCode >= 400 { return decodeAPIError(res.resp) }
+        io.Copy(io.Discard, res.resp.Body)
+        return nil
+    case <-ctx.Done():
+        cancel()
+        <-done
+        return ctx.Err()
+    case <-c.writeDL.channel():
+        cancel()
+        <-done
+        return fmt.Errorf("request aborted: deadline exceeded")
+    }
+}
+
+func (c *Client) WriteFileStream(path string, r io.Reader, size int64) error {
+    return c.WriteFileStreamContext(context.Background(), path, r, size)
+}
+
+// FileOp is a single write/delete/move operation for BatchFileOps,
+// tagged by Op ("write", "delete", or "move").
+type FileOp struct {
+    Op      string `json:"op"`
+    Path    string `json:"path,omitempty"`
+    Content string `json:"content,omitempty"`
+    From    string `json:"from,omitempty"`
+    To      string `json:"to,omitempty"`
+}
+
+// FileOpResult reports the outcome of one FileOp sent to BatchFileOps.
+type FileOpResult struct {
+    Op      string `json:"op"`
+    Path    string `json:"path,omitempty"`
+    Success bool   `json:"success"`
+    Error   string `json:"error,omitempty"`
+}
+
+// BatchFileOpsContext sends ops as a single POST /api/file/batch request
+// so a chain can atomically apply dozens of edits instead of one
+// round-trip per file. Like WriteFile/DeleteFile/MoveFile, it is not
+// auto-retried: the server isn't known to dedupe batch requests by
+// Idempotency-Key, and a batch mixing delete/move with write can't be
+// safely replayed after a lost response.
+func (c *Client) BatchFileOpsContext(ctx context.Context, ops []FileOp) ([]FileOpResult, error) {
+    resp, err := c.request(ctx, http.MethodPost, "/api/file/batch", map[string]any{"ops": ops}, c.writeDL.channel(), "")
+    if err != nil { return nil, err }
+    defer resp.Body.Close()
+    var out struct {
+        Results []FileOpResult `json:"results"`
+    }
+    if err := json.NewDecoder(resp.Body).Decode(&out); err != nil { return nil, err }
+    return out.Results, nil
+}
+
+func (c *Client) BatchFileOps(ops []FileOp) ([]FileOpResult, error) {
+    return c.BatchFileOpsContext(context.Background(), ops)
 }
 
 // Chains / Jobs
 type ExecuteResponse struct { JobID string `json:"jobId"` }
 
-func (c *Client) ExecuteChainAsJob(id string, vars map[string]any) (string, error) {
-    resp, err := c.request(http.MethodPost, "/api/chains/"+id+"/execute", map[string]any{"variables": vars, "asJob": true})
+func (c *Client) ExecuteChainAsJobContext(ctx context.Context, id string, vars map[string]any) (string, error) {
+    resp, err := c.request(ctx, http.MethodPost, "/api/chains/"+id+"/execute", map[string]any{"variables": vars, "asJob": true}, c.writeDL.channel(), newIdempotencyKey())
     if err != nil { return "", err }
     defer resp.Body.Close()
     var out ExecuteResponse
@@ -116,8 +526,12 @@ func (c *Client) ExecuteChainAsJob(id string, vars map[string]any) (string, erro
     return out.JobID, nil
 }
 
-func (c *Client) GetJob(id string) (map[string]any, error) {
-    resp, err := c.request(http.MethodGet, "/api/jobs/"+id, nil)
+func (c *Client) ExecuteChainAsJob(id string, vars map[string]any) (string, error) {
+    return c.ExecuteChainAsJobContext(context.Background(), id, vars)
+}
+
+func (c *Client) GetJobContext(ctx context.Context, id string) (map[string]any, error) {
+    resp, err := c.request(ctx, http.MethodGet, "/api/jobs/"+id, nil, c.readDL.channel(), "")
     if err != nil { return nil, err }
     defer resp.Body.Close()
     var out map[string]any
@@ -125,13 +539,17 @@ func (c *Client) GetJob(id string) (map[string]any, error) {
     return out, nil
 }
 
-func (c *Client) CancelJob(id string) error {
-    _, err := c.request(http.MethodPost, "/api/jobs/"+id+"/cancel", nil)
+func (c *Client) GetJob(id string) (map[string]any, error) { return c.GetJobContext(context.Background(), id) }
+
+func (c *Client) CancelJobContext(ctx context.Context, id string) error {
+    _, err := c.request(ctx, http.MethodPost, "/api/jobs/"+id+"/cancel", nil, c.writeDL.channel(), "")
     return err
 }
 
-func (c *Client) RetryJob(id string) (string, error) {
-    resp, err := c.request(http.MethodPost, "/api/jobs/"+id+"/retry", nil)
+func (c *Client) CancelJob(id string) error { return c.CancelJobContext(context.Background(), id) }
+
+func (c *Client) RetryJobContext(ctx context.Context, id string) (string, error) {
+    resp, err := c.request(ctx, http.MethodPost, "/api/jobs/"+id+"/retry", nil, c.writeDL.channel(), newIdempotencyKey())
     if err != nil { return "", err }
     defer resp.Body.Close()
     var out ExecuteResponse
@@ -139,3 +557,168 @@ func (c *Client) RetryJob(id string) (string, error) {
     return out.JobID, nil
 }
 
+func (c *Client) RetryJob(id string) (string, error) { return c.RetryJobContext(context.Background(), id) }
+
+// Server-Sent Events
+
+// JobEvent is a single event from a job's event stream. Type is one of
+// "status", "log", "stepStart", "stepEnd", "error", "done"; Data carries
+// the raw payload for that event (usually JSON, decode as needed).
+type JobEvent struct {
+    Type string
+    ID   string
+    Data string
+}
+
+// FileChangeEvent is a single server-pushed notification from WatchFile.
+type FileChangeEvent struct {
+    Path string `json:"path"`
+    Op   string `json:"op"`
+    Data string `json:"-"`
+}
+
+const (
+    sseInitialBackoff = 500 * time.Millisecond
+    sseMaxBackoff      = 30 * time.Second
+)
+
+// sseEvent is one parsed "data:"/"event:"/"id:" record off the wire.
+type sseEvent struct {
+    id    string
+    event string
+    data  string
+}
+
+// StreamJob consumes the job's SSE event stream and emits JobEvents on the
+// returned channel until a "done" event arrives, the context is canceled,
+// or a non-transient error occurs. It auto-reconnects with the
+// Last-Event-ID header and exponential backoff on transient errors. The
+// channel is closed when streaming stops.
+func (c *Client) StreamJob(ctx context.Context, jobID string) (<-chan JobEvent, error) {
+    if jobID == "" { return nil, fmt.Errorf("job id required") }
+    out := make(chan JobEvent)
+    go func() {
+        defer close(out)
+        c.streamSSE(ctx, "/api/jobs/"+jobID+"/events", func(ev sseEvent) bool {
+            select {
+            case out <- JobEvent{Type: ev.event, ID: ev.id, Data: ev.data}:
+            case <-ctx.Done():
+                return true
+            }
+            return ev.event == "done"
+        })
+    }()
+    return out, nil
+}
+
+// WatchFile subscribes to server-pushed change notifications for path and
+// emits FileChangeEvents until the context is canceled. It auto-reconnects
+// the same way StreamJob does.
+func (c *Client) WatchFile(ctx context.Context, path string) (<-chan FileChangeEvent, error) {
+    if path == "" { return nil, fmt.Errorf("path required") }
+    out := make(chan FileChangeEvent)
+    go func() {
+        defer close(out)
+        c.streamSSE(ctx, "/api/file/watch?path="+url.QueryEscape(path), func(ev sseEvent) bool {
+            fe := FileChangeEvent{Op: ev.event}
+            if err := json.Unmarshal([]byte(ev.data), &fe); err != nil {
+                fe.Data = ev.data
+            }
+            select {
+            case out <- fe:
+            case <-ctx.Done():
+                return true
+            }
+            return false
+        })
+    }()
+    return out, nil
+}
+
+// streamSSE opens path as an SSE stream and calls onEvent for each record
+// parsed off the wire, reconnecting with Last-Event-ID and exponential
+// backoff when the connection drops. onEvent returns true to stop.
+func (c *Client) streamSSE(ctx context.Context, path string, onEvent func(sseEvent) bool) {
+    lastEventID := ""
+    backoff := sseInitialBackoff
+    for {
+        if ctx.Err() != nil { return }
+        resp, err := c.openSSE(ctx, path, lastEventID)
+        if err != nil {
+            if ctx.Err() != nil { return }
+            // a 4xx other than 429 won't go away on reconnect
+            if ae, ok := err.(*APIError); ok && ae.Status < 500 && ae.Status != http.StatusTooManyRequests { return }
+            if !sleepCtx(ctx, backoff) { return }
+            backoff = nextSSEBackoff(backoff)
+            continue
+        }
+        backoff = sseInitialBackoff
+        stop := pumpSSE(ctx, resp.Body, &lastEventID, onEvent)
+        resp.Body.Close()
+        if stop || ctx.Err() != nil { return }
+        if !sleepCtx(ctx, backoff) { return }
+        backoff = nextSSEBackoff(backoff)
+    }
+}
+
+func (c *Client) openSSE(ctx context.Context, path, lastEventID string) (*http.Response, error) {
+    req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.BaseURL+path, nil)
+    if err != nil { return nil, err }
+    req.Header.Set("Accept", "text/event-stream")
+    if c.APIKey != "" { req.Header.Set("x-api-key", c.APIKey) }
+    if lastEventID != "" { req.Header.Set("Last-Event-ID", lastEventID) }
+    resp, err := c.http.Do(req)
+    if err != nil { return nil, err }
+    if resp.StatusCode >= 400 { return nil, decodeAPIError(resp) }
+    return resp, nil
+}
+
+// pumpSSE reads the SSE wire format (data:/event:/id: lines terminated by
+// a blank line) from r, calling onEvent for each complete record. It
+// returns true once onEvent asks to stop or the context is canceled.
+func pumpSSE(ctx context.Context, r io.Reader, lastEventID *string, onEvent func(sseEvent) bool) bool {
+    scanner := bufio.NewScanner(r)
+    scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+    var cur sseEvent
+    for scanner.Scan() {
+        if ctx.Err() != nil { return true }
+        line := scanner.Text()
+        switch {
+        case line == "":
+            if cur.data != "" || cur.event != "" {
+                if cur.id != "" { *lastEventID = cur.id }
+                if onEvent(cur) { return true }
+            }
+            cur = sseEvent{}
+        case strings.HasPrefix(line, "data:"):
+            d := strings.TrimPrefix(strings.TrimPrefix(line, "data:"), " ")
+            if cur.data != "" { cur.data += "\n" }
+            cur.data += d
+        case strings.HasPrefix(line, "event:"):
+            cur.event = strings.TrimSpace(strings.TrimPrefix(line, "event:"))
+        case strings.HasPrefix(line, "id:"):
+            cur.id = strings.TrimSpace(strings.TrimPrefix(line, "id:"))
+        default:
+            // comment line or unknown field, ignore
+        }
+    }
+    return ctx.Err() != nil
+}
+
+func sleepCtx(ctx context.Context, d time.Duration) bool {
+    t := time.NewTimer(d)
+    defer t.Stop()
+    select {
+    case <-t.C:
+        return true
+    case <-ctx.Done():
+        return false
+    }
+}
+
+func nextSSEBackoff(d time.Duration) time.Duration {
+    d *= 2
+    if d > sseMaxBackoff { d = sseMaxBackoff }
+    return d
+}
+
@@ -0,0 +1,278 @@
+// Package httpcache is an on-disk HTTP cache for a fixed set of
+// idempotent GET endpoints, used by client.Client.WithCache to avoid
+// round-tripping to the agent server for data that rarely changes.
+package httpcache
+
+import (
+    "bytes"
+    "crypto/sha256"
+    "encoding/hex"
+    "encoding/json"
+    "fmt"
+    "io"
+    "net/http"
+    "os"
+    "path/filepath"
+    "sort"
+    "strconv"
+    "strings"
+    "sync"
+    "time"
+)
+
+// defaultMaxAge is how long a cached entry is kept before it is reaped,
+// independent of the size cap.
+const defaultMaxAge = 24 * time.Hour
+
+var cacheablePaths = map[string]bool{
+    "/healthz":      true,
+    "/api/platform": true,
+    "/api/file":     true,
+}
+
+// entry is the on-disk metadata stored alongside a cached body.
+type entry struct {
+    URL          string      `json:"url"`
+    Status       int         `json:"status"`
+    Header       http.Header `json:"header"`
+    ETag         string      `json:"etag,omitempty"`
+    LastModified string      `json:"lastModified,omitempty"`
+    StoredAt     time.Time   `json:"storedAt"`
+    Size         int64       `json:"size"`
+}
+
+// Transport wraps an underlying http.RoundTripper with an on-disk cache.
+// Responses to GET /healthz, /api/platform and /api/file are stored on
+// disk keyed by method+URL+auth scope, revalidated with If-None-Match /
+// If-Modified-Since on the next request, and served stale (with a
+// Warning header) if the upstream returns a 5xx. Size is capped with LRU
+// eviction and a background goroutine reaps entries older than
+// defaultMaxAge.
+type Transport struct {
+    Next     http.RoundTripper
+    dir      string
+    maxBytes int64
+    authHash string
+
+    mu   sync.Mutex // serializes all cache directory reads/writes
+    stop chan struct{}
+}
+
+// New creates a Transport backed by dir, capped at maxBytes (0 means
+// unbounded), wrapping next (http.DefaultTransport if nil). authScope
+// distinguishes cache entries created under different credentials so one
+// client's cache can't serve another's data.
+func New(next http.RoundTripper, dir string, maxBytes int64, authScope string) (*Transport, error) {
+    if next == nil { next = http.DefaultTransport }
+    if err := os.MkdirAll(dir, 0o755); err != nil { return nil, err }
+    t := &Transport{
+        Next:     next,
+        dir:      dir,
+        maxBytes: maxBytes,
+        authHash: hashScope(authScope),
+        stop:     make(chan struct{}),
+    }
+    go t.reapLoop()
+    return t, nil
+}
+
+// Close stops the background reaper. It does not remove cached entries.
+func (t *Transport) Close() { close(t.stop) }
+
+// Purge removes every cached entry.
+func (t *Transport) Purge() error {
+    t.mu.Lock()
+    defer t.mu.Unlock()
+    des, err := os.ReadDir(t.dir)
+    if err != nil { return err }
+    for _, de := range des {
+        if err := os.Remove(filepath.Join(t.dir, de.Name())); err != nil && !os.IsNotExist(err) { return err }
+    }
+    return nil
+}
+
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+    if req.Method != http.MethodGet || !cacheablePaths[req.URL.Path] {
+        return t.Next.RoundTrip(req)
+    }
+    key := t.cacheKey(req)
+    e, body := t.load(key)
+    if e != nil {
+        if e.ETag != "" { req.Header.Set("If-None-Match", e.ETag) }
+        if e.LastModified != "" { req.Header.Set("If-Modified-Since", e.LastModified) }
+    }
+
+    resp, err := t.Next.RoundTrip(req)
+    if err != nil {
+        if e != nil { return t.stale(e, body, err.Error()), nil }
+        return nil, err
+    }
+    switch {
+    case resp.StatusCode == http.StatusNotModified && e != nil:
+        resp.Body.Close()
+        t.touch(key, e)
+        return t.respond(e, body), nil
+    case resp.StatusCode >= 500 && e != nil:
+        resp.Body.Close()
+        return t.stale(e, body, "upstream "+resp.Status), nil
+    case resp.StatusCode == http.StatusOK:
+        return t.store(key, req, resp)
+    default:
+        return resp, nil
+    }
+}
+
+func (t *Transport) store(key string, req *http.Request, resp *http.Response) (*http.Response, error) {
+    body, err := io.ReadAll(resp.Body)
+    resp.Body.Close()
+    if err != nil { return nil, err }
+    e := &entry{
+        URL:          req.URL.String(),
+        Status:       resp.StatusCode,
+        Header:       resp.Header.Clone(),
+        ETag:         resp.Header.Get("ETag"),
+        LastModified: resp.Header.Get("Last-Modified"),
+        StoredAt:     time.Now(),
+        Size:         int64(len(body)),
+    }
+    // caching is best-effort: a failed write still returns the live
+    // response to the caller
+    _ = t.save(key, e, body)
+    resp.Body = io.NopCloser(bytes.NewReader(body))
+    return resp, nil
+}
+
+func (t *Transport) respond(e *entry, body []byte) *http.Response {
+    return &http.Response{
+        StatusCode:    e.Status,
+        Status:        http.StatusText(e.Status),
+        Header:        e.Header.Clone(),
+        Body:          io.NopCloser(bytes.NewReader(body)),
+        ContentLength: int64(len(body)),
+    }
+}
+
+func (t *Transport) stale(e *entry, body []byte, reason string) *http.Response {
+    resp := t.respond(e, body)
+    resp.Header.Set("Warning", fmt.Sprintf(`110 - "Response is Stale: %s"`, reason))
+    return resp
+}
+
+func (t *Transport) cacheKey(req *http.Request) string {
+    sum := sha256.Sum256([]byte(req.Method + " " + req.URL.String() + " " + t.authHash))
+    return hex.EncodeToString(sum[:])
+}
+
+func hashScope(authScope string) string {
+    sum := sha256.Sum256([]byte(authScope))
+    return hex.EncodeToString(sum[:])[:16]
+}
+
+func (t *Transport) entryPath(key string) string { return filepath.Join(t.dir, key+".json") }
+func (t *Transport) bodyPath(key string) string  { return filepath.Join(t.dir, key+".body") }
+
+func (t *Transport) load(key string) (*entry, []byte) {
+    t.mu.Lock()
+    defer t.mu.Unlock()
+    meta, err := os.ReadFile(t.entryPath(key))
+    if err != nil { return nil, nil }
+    var e entry
+    if err := json.Unmarshal(meta, &e); err != nil { return nil, nil }
+    body, err := os.ReadFile(t.bodyPath(key))
+    if err != nil { return nil, nil }
+    return &e, body
+}
+
+func (t *Transport) save(key string, e *entry, body []byte) error {
+    t.mu.Lock()
+    defer t.mu.Unlock()
+    if err := writeAtomic(t.bodyPath(key), body); err != nil { return err }
+    meta, err := json.Marshal(e)
+    if err != nil { return err }
+    if err := writeAtomic(t.entryPath(key), meta); err != nil { return err }
+    t.enforceCapLocked()
+    return nil
+}
+
+func (t *Transport) touch(key string, e *entry) {
+    e.StoredAt = time.Now()
+    meta, err := json.Marshal(e)
+    if err != nil { return }
+    t.mu.Lock()
+    defer t.mu.Unlock()
+    _ = writeAtomic(t.entryPath(key), meta)
+}
+
+func writeAtomic(path string, data []byte) error {
+    tmp := path + ".tmp-" + strconv.FormatInt(time.Now().UnixNano(), 36)
+    if err := os.WriteFile(tmp, data, 0o644); err != nil { return err }
+    return os.Rename(tmp, path)
+}
+
+// enforceCapLocked deletes the oldest entries until the cache is back
+// under maxBytes. Caller must hold t.mu.
+func (t *Transport) enforceCapLocked() {
+    if t.maxBytes <= 0 { return }
+    type file struct {
+        key      string
+        size     int64
+        storedAt time.Time
+    }
+    des, err := os.ReadDir(t.dir)
+    if err != nil { return }
+    var files []file
+    var total int64
+    for _, de := range des {
+        name := de.Name()
+        if !strings.HasSuffix(name, ".json") { continue }
+        meta, err := os.ReadFile(filepath.Join(t.dir, name))
+        if err != nil { continue }
+        var e entry
+        if err := json.Unmarshal(meta, &e); err != nil { continue }
+        size := e.Size + int64(len(meta))
+        total += size
+        files = append(files, file{key: strings.TrimSuffix(name, ".json"), size: size, storedAt: e.StoredAt})
+    }
+    if total <= t.maxBytes { return }
+    sort.Slice(files, func(i, j int) bool { return files[i].storedAt.Before(files[j].storedAt) })
+    for _, f := range files {
+        if total <= t.maxBytes { return }
+        os.Remove(t.entryPath(f.key))
+        os.Remove(t.bodyPath(f.key))
+        total -= f.size
+    }
+}
+
+func (t *Transport) reapLoop() {
+    ticker := time.NewTicker(5 * time.Minute)
+    defer ticker.Stop()
+    for {
+        select {
+        case <-ticker.C:
+            t.reapExpired()
+        case <-t.stop:
+            return
+        }
+    }
+}
+
+func (t *Transport) reapExpired() {
+    t.mu.Lock()
+    defer t.mu.Unlock()
+    des, err := os.ReadDir(t.dir)
+    if err != nil { return }
+    now := time.Now()
+    for _, de := range des {
+        name := de.Name()
+        if !strings.HasSuffix(name, ".json") { continue }
+        meta, err := os.ReadFile(filepath.Join(t.dir, name))
+        if err != nil { continue }
+        var e entry
+        if err := json.Unmarshal(meta, &e); err != nil { continue }
+        if now.Sub(e.StoredAt) > defaultMaxAge {
+            key := strings.TrimSuffix(name, ".json")
+            os.Remove(t.entryPath(key))
+            os.Remove(t.bodyPath(key))
+        }
+    }
+}
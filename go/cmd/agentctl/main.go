@@ -1,9 +1,12 @@
 package main
 
 import (
+    "context"
+    "encoding/json"
     "flag"
     "fmt"
     "os"
+    "strconv"
     c "ai-coding-agent/client"
 )
 
@@ -12,6 +15,11 @@ func main() {
     key := os.Getenv("AGENT_API_KEY")
     cli := c.New(base, key)
 
+    if dir := os.Getenv("AGENT_CACHE_DIR"); dir != "" {
+        maxBytes := getenvInt64("AGENT_CACHE_MAX_BYTES", 100*1024*1024)
+        must(cli.WithCache(dir, maxBytes))
+    }
+
     if len(os.Args) < 2 {
         usage()
         return
@@ -46,17 +54,71 @@ func main() {
     case "chain:run":
         fs := flag.NewFlagSet("chain:run", flag.ExitOnError)
         id := fs.String("id", "", "chain id")
+        follow := fs.Bool("follow", false, "stream job events until the chain finishes")
         fs.Parse(os.Args[2:])
         if *id == "" { must(fmt.Errorf("--id required")) }
         job, err := cli.ExecuteChainAsJob(*id, map[string]any{})
         must(err)
         fmt.Println(job)
+        if *follow {
+            followJob(cli, job)
+        }
+    case "cache:purge":
+        must(cli.PurgeCache())
+        fmt.Println("ok")
+    case "file:put-stream":
+        fs := flag.NewFlagSet("file:put-stream", flag.ExitOnError)
+        path := fs.String("path", "", "file path")
+        fs.Parse(os.Args[2:])
+        if *path == "" { must(fmt.Errorf("--path required")) }
+        size := int64(-1)
+        if fi, err := os.Stdin.Stat(); err == nil && fi.Mode().IsRegular() { size = fi.Size() }
+        must(cli.WriteFileStream(*path, os.Stdin, size))
+        fmt.Println("ok")
+    case "file:batch":
+        fs := flag.NewFlagSet("file:batch", flag.ExitOnError)
+        from := fs.String("from", "", "path to a JSON array of file ops")
+        fs.Parse(os.Args[2:])
+        if *from == "" { must(fmt.Errorf("--from required")) }
+        b, err := os.ReadFile(*from)
+        must(err)
+        var ops []c.FileOp
+        must(json.Unmarshal(b, &ops))
+        results, err := cli.BatchFileOps(ops)
+        must(err)
+        for _, r := range results {
+            fmt.Printf("%s %s: success=%v %s\n", r.Op, r.Path, r.Success, r.Error)
+        }
     default:
         usage()
     }
 }
 
+// followJob tails a running job's event stream and prints each event as
+// it arrives, stopping once a "done" or "error" event is seen.
+func followJob(cli *c.Client, jobID string) {
+    ctx, cancel := context.WithCancel(context.Background())
+    defer cancel()
+    events, err := cli.StreamJob(ctx, jobID)
+    must(err)
+    for ev := range events {
+        fmt.Printf("[%s] %s\n", ev.Type, ev.Data)
+        if ev.Type == "done" || ev.Type == "error" { return }
+    }
+}
+
 func getenv(k, d string) string { if v := os.Getenv(k); v != "" { return v }; return d }
+
+func getenvInt64(k string, d int64) int64 {
+    v := os.Getenv(k)
+    if v == "" { return d }
+    n, err := strconv.ParseInt(v, 10, 64)
+    if err != nil { return d }
+    return n
+}
+
 func must(err error) { if err != nil { fmt.Fprintln(os.Stderr, err); os.Exit(1) } }
-func usage() { fmt.Println("usage: agentctl [health|platform:init|file:get --path P|file:put --path P|chain:run --id ID]") }
+func usage() {
+    fmt.Println("usage: agentctl [health|platform:init|file:get --path P|file:put --path P|file:put-stream --path P|file:batch --from ops.json|chain:run --id ID [--follow]|cache:purge]")
+}
 
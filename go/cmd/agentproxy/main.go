@@ -1,11 +1,19 @@
 package main
 
 import (
+    "context"
+    "crypto/rand"
+    "encoding/hex"
+    "encoding/json"
     "log"
+    "net"
     "net/http"
     "net/http/httputil"
     "net/url"
     "os"
+    "strconv"
+    "strings"
+    "sync"
     "time"
 )
 
@@ -16,19 +24,52 @@ func main() {
     if err != nil { log.Fatal(err) }
     proxy := httputil.NewSingleHostReverseProxy(u)
 
-    // basic logging + simple rate limiting via sleep when bursty
-    last := time.Now()
+    trustedProxies := parseTrustedProxies(getenv("PROXY_TRUSTED_PROXIES", ""))
+
+    rate := getenvFloat("PROXY_RATE", 20)
+    burst := getenvFloat("PROXY_BURST", 40)
+    perIP := newLimiterSet(rate, burst)
+    // the global limiter gives the aggregate of all clients more headroom
+    // than any single client, so one bursty IP can't starve the rest
+    global := newTokenBucket(rate*8, burst*8)
+
+    proxy.Transport = &timingRoundTripper{next: http.DefaultTransport}
     proxy.ModifyResponse = func(resp *http.Response) error {
-        log.Printf("%s %s %d", resp.Request.Method, resp.Request.URL.Path, resp.StatusCode)
+        resp.Header.Set("X-Request-ID", resp.Request.Header.Get("X-Request-ID"))
         return nil
     }
+
     handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        start := time.Now()
+        reqID := r.Header.Get("X-Request-ID")
+        if reqID == "" { reqID = newRequestID() }
+        r.Header.Set("X-Request-ID", reqID)
+
         if apiKey != "" { r.Header.Set("x-api-key", apiKey) }
-        // naive throttle: sleep if requests too close (<2ms apart)
-        now := time.Now()
-        if now.Sub(last) < 2*time.Millisecond { time.Sleep(2 * time.Millisecond) }
-        last = now
-        proxy.ServeHTTP(w, r)
+
+        ip := clientIP(r, trustedProxies)
+        if !perIP.Allow(ip) || !global.Allow() {
+            // proxy.ServeHTTP (and its ModifyResponse) never runs on this
+            // path, so this is the only place that can set the header
+            w.Header().Set("X-Request-ID", reqID)
+            w.Header().Set("Retry-After", "1")
+            http.Error(w, "rate limit exceeded", http.StatusTooManyRequests)
+            logAccess(accessLog{
+                Method: r.Method, Path: r.URL.Path, Status: http.StatusTooManyRequests,
+                DurationMs: time.Since(start).Milliseconds(), RequestID: reqID,
+            })
+            return
+        }
+
+        t := &timing{}
+        r = r.WithContext(context.WithValue(r.Context(), timingCtxKey, t))
+        rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+        proxy.ServeHTTP(rec, r)
+        logAccess(accessLog{
+            Method: r.Method, Path: r.URL.Path, Status: rec.status, Bytes: rec.bytes,
+            DurationMs: time.Since(start).Milliseconds(), UpstreamMs: t.upstream.Milliseconds(),
+            RequestID: reqID,
+        })
     })
 
     addr := getenv("PROXY_ADDR", ":8080")
@@ -36,5 +77,189 @@ func main() {
     log.Fatal(http.ListenAndServe(addr, handler))
 }
 
+// request-scoped upstream timing, threaded through the request context so
+// the RoundTripper can report back to the access log line in the handler
+
+type ctxKey int
+
+const timingCtxKey ctxKey = iota
+
+type timing struct {
+    upstream time.Duration
+}
+
+type timingRoundTripper struct {
+    next http.RoundTripper
+}
+
+func (rt *timingRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+    start := time.Now()
+    resp, err := rt.next.RoundTrip(req)
+    if t, ok := req.Context().Value(timingCtxKey).(*timing); ok {
+        t.upstream = time.Since(start)
+    }
+    return resp, err
+}
+
+// structured JSON access logging
+
+type accessLog struct {
+    Time       string `json:"time"`
+    Method     string `json:"method"`
+    Path       string `json:"path"`
+    Status     int    `json:"status"`
+    Bytes      int    `json:"bytes"`
+    DurationMs int64  `json:"durationMs"`
+    UpstreamMs int64  `json:"upstreamMs,omitempty"`
+    RequestID  string `json:"requestId"`
+}
+
+func logAccess(a accessLog) {
+    a.Time = time.Now().UTC().Format(time.RFC3339)
+    b, err := json.Marshal(a)
+    if err != nil { log.Printf("access log marshal error: %v", err); return }
+    log.Println(string(b))
+}
+
+// statusRecorder captures the status code and bytes written so they can
+// be included in the access log after ServeHTTP returns.
+type statusRecorder struct {
+    http.ResponseWriter
+    status int
+    bytes  int
+}
+
+func (r *statusRecorder) WriteHeader(code int) {
+    r.status = code
+    r.ResponseWriter.WriteHeader(code)
+}
+
+func (r *statusRecorder) Write(b []byte) (int, error) {
+    n, err := r.ResponseWriter.Write(b)
+    r.bytes += n
+    return n, err
+}
+
+// token-bucket rate limiting, per client IP plus one global bucket
+
+type tokenBucket struct {
+    mu     sync.Mutex
+    rate   float64
+    burst  float64
+    tokens float64
+    last   time.Time
+}
+
+func newTokenBucket(rate, burst float64) *tokenBucket {
+    return &tokenBucket{rate: rate, burst: burst, tokens: burst, last: time.Now()}
+}
+
+func (b *tokenBucket) Allow() bool {
+    b.mu.Lock()
+    defer b.mu.Unlock()
+    now := time.Now()
+    b.tokens += now.Sub(b.last).Seconds() * b.rate
+    if b.tokens > b.burst { b.tokens = b.burst }
+    b.last = now
+    if b.tokens < 1 { return false }
+    b.tokens--
+    return true
+}
+
+// limiterSet hands out one tokenBucket per client IP, created lazily, and
+// periodically reaps buckets that have gone idle.
+type limiterSet struct {
+    mu    sync.Mutex
+    rate  float64
+    burst float64
+    byIP  map[string]*tokenBucket
+}
+
+func newLimiterSet(rate, burst float64) *limiterSet {
+    ls := &limiterSet{rate: rate, burst: burst, byIP: make(map[string]*tokenBucket)}
+    go ls.reapLoop()
+    return ls
+}
+
+func (ls *limiterSet) Allow(ip string) bool {
+    ls.mu.Lock()
+    b, ok := ls.byIP[ip]
+    if !ok {
+        b = newTokenBucket(ls.rate, ls.burst)
+        ls.byIP[ip] = b
+    }
+    ls.mu.Unlock()
+    return b.Allow()
+}
+
+func (ls *limiterSet) reapLoop() {
+    for range time.Tick(5 * time.Minute) {
+        ls.mu.Lock()
+        for ip, b := range ls.byIP {
+            b.mu.Lock()
+            idle := time.Since(b.last) > 10*time.Minute
+            b.mu.Unlock()
+            if idle { delete(ls.byIP, ip) }
+        }
+        ls.mu.Unlock()
+    }
+}
+
+// clientIP returns the IP to key rate limiting on: the immediate TCP peer,
+// unless that peer is a configured trusted proxy, in which case the
+// client-supplied X-Forwarded-For/X-Real-IP is honored instead. Without
+// this check any client could bypass per-IP limits by varying the header
+// on every request.
+func clientIP(r *http.Request, trusted []*net.IPNet) string {
+    host, _, err := net.SplitHostPort(r.RemoteAddr)
+    if err != nil { host = r.RemoteAddr }
+    if !isTrustedProxy(host, trusted) { return host }
+
+    if fwd := r.Header.Get("X-Forwarded-For"); fwd != "" {
+        if i := strings.IndexByte(fwd, ','); i >= 0 { return strings.TrimSpace(fwd[:i]) }
+        return strings.TrimSpace(fwd)
+    }
+    if rip := r.Header.Get("X-Real-IP"); rip != "" { return strings.TrimSpace(rip) }
+    return host
+}
+
+func isTrustedProxy(ip string, trusted []*net.IPNet) bool {
+    addr := net.ParseIP(ip)
+    if addr == nil { return false }
+    for _, n := range trusted {
+        if n.Contains(addr) { return true }
+    }
+    return false
+}
+
+// parseTrustedProxies parses a comma-separated list of IPs or CIDRs (e.g.
+// "10.0.0.0/8,127.0.0.1") into the form isTrustedProxy expects. Bare IPs
+// are treated as /32 (or /128 for IPv6).
+func parseTrustedProxies(v string) []*net.IPNet {
+    var nets []*net.IPNet
+    for _, p := range strings.Split(v, ",") {
+        p = strings.TrimSpace(p)
+        if p == "" { continue }
+        if !strings.Contains(p, "/") {
+            if strings.Contains(p, ":") { p += "/128" } else { p += "/32" }
+        }
+        if _, n, err := net.ParseCIDR(p); err == nil { nets = append(nets, n) }
+    }
+    return nets
+}
+
+func newRequestID() string {
+    b := make([]byte, 16)
+    if _, err := rand.Read(b); err != nil { return strconv.FormatInt(time.Now().UnixNano(), 36) }
+    return hex.EncodeToString(b)
+}
+
 func getenv(k, d string) string { if v := os.Getenv(k); v != "" { return v }; return d }
 
+func getenvFloat(k string, d float64) float64 {
+    v := os.Getenv(k)
+    if v == "" { return d }
+    f, err := strconv.ParseFloat(v, 64)
+    if err != nil { return d }
+    return f
+}
@@ -0,0 +1,35 @@
+package main
+
+import "testing"
+
+// TestRateLimitNoisyNeighbor guards against an abusive IP exhausting its
+// own per-IP burst also draining the shared global bucket, which would
+// let it starve unrelated clients even though their own per-IP buckets
+// are untouched.
+func TestRateLimitNoisyNeighbor(t *testing.T) {
+    rate, burst := 20.0, 40.0
+    perIP := newLimiterSet(rate, burst)
+    global := newTokenBucket(rate*8, burst*8)
+
+    // allow mirrors the handler's `!perIP.Allow(ip) || !global.Allow()`
+    // check: perIP is evaluated first so a per-IP rejection never spends
+    // a global token.
+    allow := func(ip string) bool { return perIP.Allow(ip) && global.Allow() }
+
+    attacker := "10.0.0.1"
+    for i := 0; i < 40; i++ {
+        if !allow(attacker) {
+            t.Fatalf("attacker request %d unexpectedly rejected", i)
+        }
+    }
+    for i := 0; i < 2000; i++ {
+        if allow(attacker) {
+            t.Fatalf("attacker request allowed past its own burst at iteration %d", i)
+        }
+    }
+
+    victim := "10.0.0.2"
+    if !allow(victim) {
+        t.Fatal("victim's first request was rejected because the attacker drained the global bucket")
+    }
+}